@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// detectPprofKind makes a best-effort guess at a profile's pprof kind from
+// its filename, since that's all DirListRich and /api/profiles have to go
+// on without actually parsing the profile.
+func detectPprofKind(name string) string {
+	if !isProfileFile(name) {
+		return ""
+	}
+
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "heap"):
+		return "heap"
+	case strings.Contains(lower, "goroutine"):
+		return "goroutine"
+	case strings.Contains(lower, "block"):
+		return "block"
+	case strings.Contains(lower, "mutex"):
+		return "mutex"
+	case strings.Contains(lower, "trace"):
+		return "trace"
+	case strings.Contains(lower, "cpu") || strings.Contains(lower, "profile"):
+		return "cpu"
+	default:
+		return "unknown"
+	}
+}
+
+type jsonEntry struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	MTime     time.Time `json:"mtime"`
+	IsDir     bool      `json:"isDir"`
+	Type      string    `json:"type"`
+	PprofKind string    `json:"pprofKind,omitempty"`
+}
+
+type jsonListing struct {
+	Path    string      `json:"path"`
+	Entries []jsonEntry `json:"entries"`
+	Parent  string      `json:"parent"`
+}
+
+// writeJSONListing renders dirs as the JSON counterpart of the HTML directory
+// listing, for requests with ?format=json or an "Accept: application/json" header.
+func writeJSONListing(w http.ResponseWriter, relPath string, dirs []os.FileInfo) error {
+	listing := jsonListing{
+		Path:    relPath,
+		Entries: make([]jsonEntry, 0, len(dirs)),
+		Parent:  parentPath(relPath),
+	}
+
+	for _, d := range dirs {
+		entry := jsonEntry{
+			Name:  d.Name(),
+			Size:  d.Size(),
+			MTime: d.ModTime(),
+			IsDir: d.IsDir(),
+			Type:  "file",
+		}
+		if d.IsDir() {
+			entry.Type = "dir"
+		} else {
+			entry.PprofKind = detectPprofKind(d.Name())
+		}
+		listing.Entries = append(listing.Entries, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(listing)
+}
+
+// wantsJSON reports whether the request asked for the JSON listing format,
+// either explicitly via ?format=json or via content negotiation.
+func wantsJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+type apiProfileEntry struct {
+	Path         string    `json:"path"`
+	LoadedAt     time.Time `json:"loadedAt"`
+	TTLRemaining string    `json:"ttlRemaining"`
+	Kind         string    `json:"kind"`
+}
+
+// apiProfilesHandler serves GET /api/profiles: every PProfHandler currently
+// in profileCache, so external tooling can script against pprofsvr as a
+// profile registry instead of just a browser. Compare-mode handlers held in
+// compareCache are not listed.
+func apiProfilesHandler(c *gin.Context) {
+	entries := make([]apiProfileEntry, 0)
+
+	profileCache.Range(func(fp string, ph *PProfHandler) bool {
+		remaining := profileTTL - time.Since(ph.time)
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		entries = append(entries, apiProfileEntry{
+			Path:         fp,
+			LoadedAt:     ph.time,
+			TTLRemaining: remaining.Round(time.Second).String(),
+			Kind:         detectPprofKind(fp),
+		})
+		return true
+	})
+
+	c.JSON(http.StatusOK, entries)
+}
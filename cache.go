@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"container/list"
+	"expvar"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// cacheMaxEntries caps profileCache by entry count; 0 means unlimited.
+var cacheMaxEntries int
+
+// cacheMaxBytes caps profileCache by estimated heap cost (bytes allocated
+// while loading each profile, summed across cached entries); 0 means unlimited.
+var cacheMaxBytes int64
+
+// cacheRSSWatermark additionally makes each Store evict least-recently-used
+// entries, down to the one just stored, while process RSS is above this many
+// bytes; 0 disables the check.
+var cacheRSSWatermark int64
+
+type cacheEntry struct {
+	key     string
+	handler *PProfHandler
+	cost    int64
+}
+
+// lruCache is a size- and entry-count-bounded, RSS-aware LRU cache of
+// PProfHandlers. It replaces the original unbounded sync.Map, which kept
+// every opened profile resident until its TTL expired regardless of how
+// many had accumulated — fine for a handful of profiles, but a reliable
+// way to OOM the server once a repo holds hundreds of them.
+type lruCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element // key -> element holding *cacheEntry
+	order   *list.List               // front = most recently used
+
+	hits      expvar.Int
+	misses    expvar.Int
+	evictions expvar.Int
+}
+
+func newLRUCache(name string) *lruCache {
+	c := &lruCache{
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+	expvar.Publish(name+"_hits", &c.hits)
+	expvar.Publish(name+"_misses", &c.misses)
+	expvar.Publish(name+"_evictions", &c.evictions)
+	return c
+}
+
+func (c *lruCache) Load(key string) (*PProfHandler, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	c.hits.Add(1)
+	return elem.Value.(*cacheEntry).handler, true
+}
+
+func (c *lruCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleteLocked(key)
+}
+
+func (c *lruCache) deleteLocked(key string) {
+	elem, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	c.order.Remove(elem)
+	delete(c.entries, key)
+}
+
+// Store adds handler under key with the given cost (bytes), then evicts
+// least-recently-used entries until the cache is back under the configured
+// entry-count, byte-cost, and process-RSS limits. The entry just stored is
+// never evicted here, even when it alone is over a limit.
+func (c *lruCache) Store(key string, handler *PProfHandler, cost int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deleteLocked(key)
+	elem := c.order.PushFront(&cacheEntry{key: key, handler: handler, cost: cost})
+	c.entries[key] = elem
+
+	c.evictLocked()
+}
+
+func (c *lruCache) totalCostLocked() int64 {
+	var total int64
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		total += elem.Value.(*cacheEntry).cost
+	}
+	return total
+}
+
+func (c *lruCache) evictLocked() {
+	for c.shouldEvictLocked() {
+		back := c.order.Back()
+		if back == nil {
+			return
+		}
+		delete(c.entries, back.Value.(*cacheEntry).key)
+		c.order.Remove(back)
+		c.evictions.Add(1)
+	}
+}
+
+func (c *lruCache) shouldEvictLocked() bool {
+	if len(c.entries) <= 1 {
+		// Never evict the only entry left, even over a byte/RSS limit —
+		// that would just be evicted again on the very next request.
+		return false
+	}
+	if cacheMaxEntries > 0 && len(c.entries) > cacheMaxEntries {
+		return true
+	}
+	if cacheMaxBytes > 0 && c.totalCostLocked() > cacheMaxBytes {
+		return true
+	}
+	if cacheRSSWatermark > 0 && currentRSS() > uint64(cacheRSSWatermark) {
+		return true
+	}
+	return false
+}
+
+// Range calls f for every cached entry; f returning false stops iteration early.
+func (c *lruCache) Range(f func(key string, handler *PProfHandler) bool) {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.entries))
+	handlers := make([]*PProfHandler, 0, len(c.entries))
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		e := elem.Value.(*cacheEntry)
+		keys = append(keys, e.key)
+		handlers = append(handlers, e.handler)
+	}
+	c.mu.Unlock()
+
+	for i := range keys {
+		if !f(keys[i], handlers[i]) {
+			return
+		}
+	}
+}
+
+// measureAlloc runs fn and returns the net increase in heap bytes allocated
+// while it ran, used as the resulting cache entry's cost.
+func measureAlloc(fn func() error) (int64, error) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	err := fn()
+	runtime.ReadMemStats(&after)
+
+	cost := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	if cost < 0 {
+		cost = 0
+	}
+	return cost, err
+}
+
+// currentRSS returns the process's resident set size, read from
+// /proc/self/status on Linux. Falls back to runtime.MemStats.Sys elsewhere.
+func currentRSS() uint64 {
+	if f, err := os.Open("/proc/self/status"); err == nil {
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 3 && fields[0] == "VmRSS:" {
+				if kb, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+					return kb * 1024
+				}
+			}
+		}
+	}
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.Sys
+}
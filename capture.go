@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// Target describes a running Go program to periodically pull profiles from,
+// via its net/http/pprof endpoints.
+type Target struct {
+	Name     string        `yaml:"name"`
+	Addr     string        `yaml:"addr"`
+	Kind     string        `yaml:"kind"`
+	Seconds  int           `yaml:"seconds"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// targetsFile points at a YAML config of the form:
+//
+//	targets:
+//	  - name: foo
+//	    addr: localhost:6060
+//	    kind: cpu
+//	    seconds: 30
+//	    interval: 5m
+var targetsFile string
+
+// targetFlagValue implements flag.Value for repeatable -target flags of the
+// form "name=addr,kind=cpu,seconds=30,interval=5m".
+type targetFlagValue []Target
+
+func (v *targetFlagValue) String() string {
+	return fmt.Sprintf("%v", []Target(*v))
+}
+
+func (v *targetFlagValue) Set(s string) error {
+	parts := strings.Split(s, ",")
+	nameAddr := strings.SplitN(parts[0], "=", 2)
+	if len(nameAddr) != 2 || nameAddr[0] == "" || nameAddr[1] == "" {
+		return fmt.Errorf("invalid -target %q: expected name=addr,kind=cpu,seconds=30,interval=5m", s)
+	}
+
+	t := Target{Name: nameAddr[0], Addr: nameAddr[1], Kind: "cpu", Seconds: 30, Interval: 5 * time.Minute}
+	for _, attr := range parts[1:] {
+		kv := strings.SplitN(attr, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "kind", "type":
+			t.Kind = kv[1]
+		case "seconds":
+			if n, err := strconv.Atoi(kv[1]); err == nil {
+				t.Seconds = n
+			}
+		case "interval":
+			if d, err := time.ParseDuration(kv[1]); err == nil {
+				t.Interval = d
+			}
+		}
+	}
+
+	*v = append(*v, t)
+	return nil
+}
+
+var cliTargets targetFlagValue
+
+// targetRegistry holds every configured Target by name, so POST
+// /api/capture can look one up for an on-demand pull.
+var targetRegistry sync.Map
+
+func loadTargetsFile(path string) ([]Target, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg struct {
+		Targets []Target `yaml:"targets"`
+	}
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cfg.Targets, nil
+}
+
+// startCaptureTargets loads the configured targets (from -targets and
+// repeated -target flags) and starts a background collector for each.
+func startCaptureTargets() {
+	var targets []Target
+
+	if targetsFile != "" {
+		loaded, err := loadTargetsFile(targetsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "pprofsvr: load targets file %s: %v\n", targetsFile, err)
+		} else {
+			targets = append(targets, loaded...)
+		}
+	}
+	targets = append(targets, cliTargets...)
+
+	for _, t := range targets {
+		if t.Interval <= 0 {
+			t.Interval = 5 * time.Minute
+		}
+		if t.Seconds <= 0 {
+			t.Seconds = 30
+		}
+		if t.Kind == "" {
+			t.Kind = "cpu"
+		}
+
+		targetRegistry.Store(t.Name, t)
+		go runCaptureLoop(t)
+	}
+}
+
+func runCaptureLoop(t Target) {
+	for {
+		if _, err := captureOnce(t); err != nil {
+			fmt.Fprintf(os.Stderr, "pprofsvr: capture %s: %v\n", t.Name, err)
+		}
+		time.Sleep(t.Interval)
+	}
+}
+
+// allowedCaptureKinds is the fixed set of net/http/pprof profile kinds
+// captureOnce will fetch. t.Kind can be overridden per-request via
+// POST /api/capture?kind=..., so it must never reach the remote URL or the
+// on-disk filename unvalidated.
+var allowedCaptureKinds = map[string]bool{
+	"cpu":          true,
+	"heap":         true,
+	"goroutine":    true,
+	"block":        true,
+	"mutex":        true,
+	"allocs":       true,
+	"threadcreate": true,
+	"trace":        true,
+}
+
+// remotePprofPath returns the net/http/pprof path to fetch for kind.
+func remotePprofPath(kind string, seconds int) string {
+	switch kind {
+	case "cpu":
+		return fmt.Sprintf("/debug/pprof/profile?seconds=%d", seconds)
+	case "heap", "goroutine", "block", "mutex", "allocs", "threadcreate":
+		return "/debug/pprof/" + kind
+	default:
+		return "/debug/pprof/" + kind
+	}
+}
+
+// captureOnce pulls a single profile from t and writes it under
+// repoPath/<t.Name>/<kind>-<timestamp>.pb.gz, then warms the pprof handler cache
+// for it so it's immediately browsable.
+func captureOnce(t Target) (string, error) {
+	if !allowedCaptureKinds[t.Kind] {
+		return "", fmt.Errorf("capture %s: unsupported kind %q", t.Name, t.Kind)
+	}
+
+	client := &http.Client{Timeout: time.Duration(t.Seconds+10) * time.Second}
+
+	url := "http://" + t.Addr + remotePprofPath(t.Kind, t.Seconds)
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch %s: unexpected status %s", url, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("read profile from %s: %w", url, err)
+	}
+
+	dir := filepath.Join(repoPath, t.Name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s-%s.pb.gz", t.Kind, time.Now().Format("20060102-150405"))
+	fp, err := resolveJailed(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(fp, body, 0o644); err != nil {
+		return "", err
+	}
+
+	if _, err := getHandler(fp); err != nil {
+		return fp, fmt.Errorf("warm handler for %s: %w", fp, err)
+	}
+
+	return fp, nil
+}
+
+// captureHandler handles POST /api/capture?target=foo&kind=cpu&seconds=30:
+// an on-demand pull against a registered target, optionally overriding its
+// configured kind/seconds for this one capture.
+func captureHandler(c *gin.Context) {
+	if !checkCSRF(c) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	name := c.Query("target")
+	v, ok := targetRegistry.Load(name)
+	if !ok {
+		c.AbortWithError(http.StatusNotFound, fmt.Errorf("unknown target %q", name))
+		return
+	}
+	t := v.(Target)
+
+	if kind := c.Query("kind"); kind != "" {
+		t.Kind = kind
+	}
+	if seconds := c.Query("seconds"); seconds != "" {
+		if n, err := strconv.Atoi(seconds); err == nil {
+			t.Seconds = n
+		}
+	}
+
+	fp, err := captureOnce(t)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	rel, err := filepath.Rel(repoPath, fp)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	c.Redirect(http.StatusFound, "/"+filepath.ToSlash(rel)+"/ui/")
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestAllowedCaptureKinds(t *testing.T) {
+	for _, kind := range []string{"cpu", "heap", "goroutine", "block", "mutex", "allocs", "threadcreate", "trace"} {
+		if !allowedCaptureKinds[kind] {
+			t.Errorf("expected %q to be an allowed capture kind", kind)
+		}
+	}
+
+	for _, kind := range []string{"../../etc/passwd", "cpu/../../etc", "cpu/x", "unknown", ""} {
+		if allowedCaptureKinds[kind] {
+			t.Errorf("expected %q to be rejected as a capture kind", kind)
+		}
+	}
+}
+
+func TestCaptureOnceRejectsUnsafeKind(t *testing.T) {
+	for _, kind := range []string{"../../etc", "cpu/../../etc", "cpu/x", "unknown", ""} {
+		if _, err := captureOnce(Target{Name: "t", Addr: "127.0.0.1:0", Kind: kind, Seconds: 1}); err == nil {
+			t.Errorf("captureOnce with kind %q: expected error, got nil", kind)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/pprof/driver"
+)
+
+var (
+	// compareCache caches compare-mode PProfHandlers keyed by a short hash of
+	// their composite "mode:a|b" key, since the key itself isn't URL-safe.
+	// It shares the same bounded-LRU + RSS eviction behavior as profileCache.
+	compareCache = newLRUCache("compare")
+	// compareKeyToHash lets repeated requests for the same pair reuse the
+	// already-launched driver instance instead of spawning a new one.
+	compareKeyToHash sync.Map
+	compareMu        sync.Mutex
+)
+
+// wrapHandlersWithNav wraps each of args.Handlers with the top navigation
+// bar, deriving the "up" link from the request path itself (stripping the
+// "/ui"+registered-subpath suffix) so it works whether the handler is
+// addressed directly by file path or under /compare/<hash>, where "up"
+// leads to the root listing.
+func wrapHandlersWithNav(args *driver.HTTPServerArgs) {
+	originalHandlers := make(map[string]http.Handler, len(args.Handlers))
+	for k, v := range args.Handlers {
+		originalHandlers[k] = v
+	}
+
+	for p, handler := range originalHandlers {
+		p, handler := p, handler
+		args.Handlers[p] = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			currentPath := r.URL.Path
+			profilePath := strings.TrimSuffix(currentPath, "/ui"+p)
+			baseDirPath := filepath.Dir(profilePath)
+			if strings.HasPrefix(profilePath, "/compare/") {
+				baseDirPath = ""
+			}
+			if !strings.HasSuffix(currentPath, "/ui/download") {
+				w.Write([]byte(makeNavHTML(baseDirPath)))
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// getCompareHandler launches (or reuses) a pprof driver instance diffing
+// profile b against base profile a, returning the hash used to address it
+// under /compare/<hash>/ui/.
+func getCompareHandler(mode, a, b string) (string, error) {
+	if mode != "base" {
+		mode = "diff_base"
+	}
+	key := fmt.Sprintf("%s:%s|%s", mode, a, b)
+
+	if h, ok := compareKeyToHash.Load(key); ok {
+		hash := h.(string)
+		if _, loaded := compareCache.Load(hash); loaded {
+			return hash, nil
+		}
+		// compareCache evicted this hash under memory/RSS pressure; fall
+		// through and relaunch the driver under the same deterministic hash.
+		compareKeyToHash.Delete(key)
+	}
+
+	compareMu.Lock()
+	defer compareMu.Unlock()
+
+	if h, ok := compareKeyToHash.Load(key); ok {
+		hash := h.(string)
+		if _, loaded := compareCache.Load(hash); loaded {
+			return hash, nil
+		}
+		compareKeyToHash.Delete(key)
+	}
+
+	sum := sha1.Sum([]byte(key))
+	hash := hex.EncodeToString(sum[:])[:12]
+
+	ph := &PProfHandler{}
+	opts := &driver.Options{
+		UI: &nullUI{},
+		HTTPServer: func(args *driver.HTTPServerArgs) error {
+			ph.time = time.Now()
+			ph.args = args
+			wrapHandlersWithNav(args)
+			return nil
+		},
+		HTTPTransport: http.DefaultTransport,
+		Flagset:       NewGoFlags([]string{"-http", ":8888", "--no_browser", "-" + mode + "=" + a, b}),
+	}
+
+	cost, err := measureAlloc(func() error { return driver.PProf(opts) })
+	if err != nil {
+		return "", err
+	}
+
+	compareCache.Store(hash, ph, cost)
+	compareKeyToHash.Store(key, hash)
+	return hash, nil
+}
+
+// compareHandler handles POST /compare: it takes exactly two "paths" form
+// values (relative to repoPath) and redirects to the diff UI for them.
+func compareHandler(c *gin.Context) {
+	if !checkCSRF(c) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	paths := c.PostFormArray("paths")
+	if len(paths) != 2 {
+		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("compare requires exactly 2 selected paths, got %d", len(paths)))
+		return
+	}
+
+	mode := c.DefaultPostForm("mode", "diff_base")
+	a, err := resolveJailed(filepath.Join(repoPath, paths[0]))
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	b, err := resolveJailed(filepath.Join(repoPath, paths[1]))
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	hash, err := getCompareHandler(mode, a, b)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/compare/"+hash+"/ui/")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// allowSymlinks disables jail enforcement entirely when set, restoring the
+// original unchecked filepath.Join behavior.
+var allowSymlinks bool
+
+// extraRootsFlag collects -allow-root values: additional absolute roots
+// (beyond repoPath) that a resolved path is allowed to land in, e.g. for
+// profiles symlinked in from another volume.
+type extraRootsFlag []string
+
+func (f *extraRootsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *extraRootsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+var extraRoots extraRootsFlag
+
+// jailRoots holds the symlink-resolved, absolute form of repoPath plus
+// every -allow-root, computed once in initJail. jailRoots[0] is always the
+// resolved repoPath itself.
+var jailRoots []string
+
+// initJail resolves repoPath and extraRoots once at startup. It must run
+// after flags are parsed and repoPath has its final value.
+func initJail() {
+	jailRoots = nil
+	for _, root := range append([]string{repoPath}, extraRoots...) {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		resolved, err := filepath.EvalSymlinks(abs)
+		if err != nil {
+			resolved = abs
+		}
+		jailRoots = append(jailRoots, filepath.Clean(resolved))
+	}
+}
+
+// isRepoRoot reports whether p is repoPath itself (resolved form), used to
+// refuse deleting the whole repo out from under the server.
+func isRepoRoot(p string) bool {
+	return len(jailRoots) > 0 && filepath.Clean(p) == jailRoots[0]
+}
+
+func withinJail(p string) bool {
+	p = filepath.Clean(p)
+	for _, root := range jailRoots {
+		if p == root || strings.HasPrefix(p, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveJailed resolves p (already filepath.Join'd with repoPath) to its
+// symlink-free absolute form and verifies it still falls under repoPath or
+// one of the -allow-root roots, closing the usual symlink-escape and ".."
+// traversal holes. It tolerates a not-yet-existing tail (e.g. an upload
+// destination or a new mkdir'd folder) by resolving symlinks on the
+// longest existing ancestor and re-appending the rest unresolved.
+func resolveJailed(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+
+	if allowSymlinks {
+		return abs, nil
+	}
+
+	resolved, rest, err := evalSymlinksTolerant(abs)
+	if err != nil {
+		return "", err
+	}
+
+	full := filepath.Join(resolved, rest)
+	if !withinJail(full) {
+		return "", fmt.Errorf("path %q escapes the allowed roots", p)
+	}
+
+	return full, nil
+}
+
+// evalSymlinksTolerant resolves symlinks on the longest existing prefix of
+// p, returning that resolved prefix plus the (possibly not yet existing)
+// remainder joined back on by the caller.
+func evalSymlinksTolerant(p string) (resolved, rest string, err error) {
+	cur := p
+	var tail []string
+	for {
+		r, err := filepath.EvalSymlinks(cur)
+		if err == nil {
+			return r, filepath.Join(tail...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", "", err
+		}
+
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			return "", "", err
+		}
+		tail = append([]string{filepath.Base(cur)}, tail...)
+		cur = parent
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"expvar"
 	"flag"
 	"fmt"
 	"io"
@@ -50,7 +51,7 @@ type PProfHandler struct {
 }
 
 var (
-	profileMap     sync.Map
+	profileCache   = newLRUCache("profile")
 	mu             sync.Mutex
 	profileTTL     time.Duration = 30 * time.Minute // 设置profile缓存30分钟
 	watcherRunning bool
@@ -60,11 +61,9 @@ var (
 func cleanupProfiles() {
 	for {
 		time.Sleep(5 * time.Minute) // 每5分钟检查一次
-		profileMap.Range(func(key, value interface{}) bool {
-			if ph, ok := value.(*PProfHandler); ok {
-				if time.Since(ph.time) > profileTTL {
-					profileMap.Delete(key)
-				}
+		profileCache.Range(func(key string, ph *PProfHandler) bool {
+			if time.Since(ph.time) > profileTTL {
+				profileCache.Delete(key)
 			}
 			return true
 		})
@@ -112,17 +111,21 @@ func makeNavHTML(baseDirPath string) string {
 
 // 修改后的getHandler函数
 func getHandler(fp string) (*PProfHandler, error) {
+	fp, err := resolveJailed(fp)
+	if err != nil {
+		return nil, err
+	}
+
 	// 检查文件修改时间
 	info, err := os.Stat(fp)
 	if err != nil {
 		return nil, err
 	}
 
-	if v, loaded := profileMap.Load(fp); loaded {
-		ph := v.(*PProfHandler)
+	if ph, loaded := profileCache.Load(fp); loaded {
 		// 检查文件是否被修改
 		if info.ModTime().After(ph.time) {
-			profileMap.Delete(fp) // 文件已修改，删除旧缓存
+			profileCache.Delete(fp) // 文件已修改，删除旧缓存
 		} else {
 			return ph, nil
 		}
@@ -132,8 +135,8 @@ func getHandler(fp string) (*PProfHandler, error) {
 	defer mu.Unlock()
 
 	// 再次检查，防止并发创建
-	if v, loaded := profileMap.Load(fp); loaded {
-		return v.(*PProfHandler), nil
+	if ph, loaded := profileCache.Load(fp); loaded {
+		return ph, nil
 	}
 
 	ph := &PProfHandler{}
@@ -142,39 +145,19 @@ func getHandler(fp string) (*PProfHandler, error) {
 		HTTPServer: func(args *driver.HTTPServerArgs) error {
 			ph.time = time.Now()
 			ph.args = args
-
-			// 保存原始handler
-			originalHandlers := make(map[string]http.Handler)
-			for k, v := range args.Handlers {
-				originalHandlers[k] = v
-			}
-
-			// 添加导航栏包装器
-			for path, handler := range originalHandlers {
-				args.Handlers[path] = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-					// 获取当前请求路径
-					currentPath := r.URL.Path
-					// 提取基础路径（如/test/cpu2.out）
-					profilePath := strings.TrimSuffix(currentPath, "/ui"+path)
-					baseDirPath := filepath.Dir(profilePath)
-					// 生成导航栏HTML
-					if !strings.HasSuffix(currentPath, "/ui/download") {
-						w.Write([]byte(makeNavHTML(baseDirPath)))
-					}
-					handler.ServeHTTP(w, r)
-				})
-			}
+			wrapHandlersWithNav(args)
 			return nil
 		},
 		HTTPTransport: http.DefaultTransport,
 		Flagset:       NewGoFlags([]string{"-http", ":8888", "--no_browser", fp}),
 	}
 
-	if err := driver.PProf(opts); err != nil {
+	cost, err := measureAlloc(func() error { return driver.PProf(opts) })
+	if err != nil {
 		return nil, err
 	}
 
-	profileMap.Store(fp, ph)
+	profileCache.Store(fp, ph, cost)
 	return ph, nil
 }
 
@@ -186,6 +169,14 @@ var (
 func init() {
 	flag.StringVar(&repoPath, "p", "", "repository path")
 	flag.StringVar(&addr, "addr", "", "listen addr, default: :26817")
+	flag.BoolVar(&readonly, "readonly", false, "disable upload/delete/mkdir/capture routes")
+	flag.StringVar(&targetsFile, "targets", "", "path to a targets.yaml describing remote pprof endpoints to capture from")
+	flag.Var(&cliTargets, "target", "capture target as name=addr,kind=cpu,seconds=30,interval=5m (repeatable)")
+	flag.IntVar(&cacheMaxEntries, "cache-max-entries", 0, "max cached profile handlers (0 = unlimited)")
+	flag.Int64Var(&cacheMaxBytes, "cache-max-bytes", 0, "max estimated heap bytes held by cached profile handlers (0 = unlimited)")
+	flag.Int64Var(&cacheRSSWatermark, "cache-rss-watermark", 0, "evict cached profile handlers while process RSS is above this many bytes (0 = disabled)")
+	flag.BoolVar(&allowSymlinks, "allow-symlinks", false, "allow serving paths that resolve (via symlink or ..) outside -p, skipping jail enforcement")
+	flag.Var(&extraRoots, "allow-root", "additional absolute path a resolved symlink is allowed to land in, beyond -p (repeatable)")
 }
 
 func main() {
@@ -196,9 +187,11 @@ func main() {
 	if addr == "" {
 		addr = ":26817"
 	}
+	initJail()
 
 	// 在main函数开始时启动清理goroutine
 	go cleanupProfiles()
+	startCaptureTargets()
 
 	r := gin.Default()
 
@@ -215,6 +208,42 @@ func main() {
 
 	// Register GET and HEAD handlers
 	r.GET("/*filepath", func(c *gin.Context) {
+		if c.Request.URL.Path == "/api/profiles" {
+			apiProfilesHandler(c)
+			return
+		}
+
+		if c.Request.URL.Path == "/debug/vars" {
+			expvar.Handler().ServeHTTP(c.Writer, c.Request)
+			return
+		}
+
+		if rest, ok := strings.CutPrefix(c.Request.URL.Path, "/compare/"); ok {
+			hash, after, ok := strings.Cut(rest, "/ui")
+			if !ok {
+				c.Writer.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			ph, loaded := compareCache.Load(hash)
+			if !loaded {
+				c.Writer.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			if after == "" {
+				after = "/"
+			}
+
+			if handler, ok := ph.args.Handlers[after]; ok {
+				handler.ServeHTTP(c.Writer, c.Request)
+				return
+			}
+
+			c.Writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+
 		if before, after, ok := strings.Cut(c.Request.URL.Path, "/ui"); ok {
 			// load http handlers
 			fp := filepath.Join(repoPath, before)
@@ -234,8 +263,15 @@ func main() {
 			}
 		} else {
 			file := c.Param("filepath")
-			// Check if file exists and/or if we have permission to access it
-			info, err := os.Stat(filepath.Join(repoPath, file))
+			// Check if file exists and/or if we have permission to access it,
+			// rejecting symlink/".." escapes out of repoPath (or -allow-root).
+			resolved, err := resolveJailed(filepath.Join(repoPath, file))
+			if err != nil {
+				c.Writer.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			info, err := os.Stat(resolved)
 			if err != nil {
 				c.Writer.WriteHeader(http.StatusNotFound)
 				return
@@ -255,5 +291,32 @@ func main() {
 		}
 	})
 
+	// Compare is read-only analysis, so it's handled before the readonly gate
+	// even though it shares the catch-all route with the mutating handlers
+	// (gin's router rejects a static "/compare" route alongside "/*filepath").
+	r.POST("/*filepath", func(c *gin.Context) {
+		switch c.Param("filepath") {
+		case "/compare":
+			compareHandler(c)
+			return
+		case "/api/capture":
+			if readonly {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			captureHandler(c)
+			return
+		}
+		if readonly {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		uploadHandler(c)
+	})
+
+	if !readonly {
+		r.DELETE("/*filepath", deleteHandler)
+	}
+
 	r.Run(addr)
 }
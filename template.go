@@ -9,29 +9,68 @@ import (
 	"os"
 	"path"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/kataras/httpfs"
 )
 
+const defaultPerPage = 50
+
 type (
 	listPageData struct {
-		Title   string // the document's title.
-		Files   []fileInfoData
-		RelPath string // the request path.
+		Title     string // the document's title.
+		Files     []fileInfoData
+		RelPath   string // the request path.
+		CSRFToken string // embedded in upload/mkdir forms and delete requests.
+		Readonly  bool   // hides upload/delete/mkdir controls when true.
+
+		Query       string // the current ?q= filter.
+		Sort        string // the current ?sort= column.
+		Order       string // the current ?order= direction.
+		NameOrder   string // order to use in the "name" header link.
+		SizeOrder   string // order to use in the "size" header link.
+		TimeOrder   string // order to use in the "time" header link.
+		NumDirs     int    // directories matching the current filter.
+		NumFiles    int    // files matching the current filter.
+		Page        int
+		PerPage     int
+		TotalPages  int
+		PrevPageURL string
+		NextPageURL string
 	}
 
 	fileInfoData struct {
-		Info     os.FileInfo
-		ModTime  string // format-ed time.
-		Path     string // the request path.
-		RelPath  string // file path without the system directory itself (we are not exposing it to the user).
-		Name     string // the html-escaped name.
-		Download bool   // the file should be downloaded (attachment instead of inline view).
+		Info      os.FileInfo
+		ModTime   string // format-ed time.
+		Path      string // the request path.
+		RelPath   string // file path without the system directory itself (we are not exposing it to the user).
+		Name      string // the html-escaped name.
+		Download  bool   // the file should be downloaded (attachment instead of inline view).
+		IsProfile bool   // shows the compare checkbox; a regular (non-directory) profile file.
 	}
 )
 
+// parentPath returns the directory above p, e.g. "/a/b/" -> "/a".
+func parentPath(p string) string {
+	if isRoot(p) {
+		return "/"
+	}
+	if strings.HasSuffix(p, "/") {
+		p = p[:len(p)-1]
+	}
+	lastSlash := strings.LastIndex(p, "/")
+	if lastSlash == 0 {
+		return "/" // 如果是根目录，返回"/"
+	}
+	return p[:lastSlash] // 返回父目录路径
+}
+
+func isRoot(path string) bool {
+	return path == "/" || path == ""
+}
+
 func toBaseName(s string) string {
 	n := len(s) - 1
 	for i := n; i >= 0; i-- {
@@ -62,14 +101,114 @@ func DirListRich(options httpfs.DirListRichOptions) httpfs.DirListFunc {
 			return err
 		}
 
+		q := r.URL.Query().Get("q")
+		if q != "" {
+			lowerQ := strings.ToLower(q)
+			filtered := make([]os.FileInfo, 0, len(dirs))
+			for _, d := range dirs {
+				if strings.Contains(strings.ToLower(d.Name()), lowerQ) {
+					filtered = append(filtered, d)
+				}
+			}
+			dirs = filtered
+		}
+
 		sortBy := r.URL.Query().Get("sort")
-		switch sortBy {
-		case "name":
-			sort.Slice(dirs, func(i, j int) bool { return dirs[i].Name() < dirs[j].Name() })
-		case "size":
-			sort.Slice(dirs, func(i, j int) bool { return dirs[i].Size() < dirs[j].Size() })
-		default:
-			sort.Slice(dirs, func(i, j int) bool { return dirs[i].ModTime().After(dirs[j].ModTime()) })
+		if sortBy == "" {
+			sortBy = "time"
+		}
+		order := r.URL.Query().Get("order")
+		if order == "" {
+			if sortBy == "time" {
+				order = "desc"
+			} else {
+				order = "asc"
+			}
+		}
+
+		less := func(i, j int) bool {
+			switch sortBy {
+			case "size":
+				return dirs[i].Size() < dirs[j].Size()
+			case "time":
+				return dirs[i].ModTime().Before(dirs[j].ModTime())
+			default:
+				return dirs[i].Name() < dirs[j].Name()
+			}
+		}
+		if order == "desc" {
+			sort.Slice(dirs, func(i, j int) bool { return less(j, i) })
+		} else {
+			sort.Slice(dirs, func(i, j int) bool { return less(i, j) })
+		}
+
+		// nextOrder returns the order a header link for col should point to:
+		// the opposite of the current order if col is already active,
+		// otherwise each column's natural starting direction.
+		nextOrder := func(col string) string {
+			if sortBy == col {
+				if order == "asc" {
+					return "desc"
+				}
+				return "asc"
+			}
+			if col == "time" {
+				return "desc"
+			}
+			return "asc"
+		}
+
+		numDirs, numFiles := 0, 0
+		for _, d := range dirs {
+			if d.IsDir() {
+				numDirs++
+			} else {
+				numFiles++
+			}
+		}
+
+		if wantsJSON(r) {
+			return writeJSONListing(w, r.URL.Path, dirs)
+		}
+
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		if perPage <= 0 {
+			perPage = defaultPerPage
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+
+		total := len(dirs)
+		totalPages := (total + perPage - 1) / perPage
+		if totalPages < 1 {
+			totalPages = 1
+		}
+		if page > totalPages {
+			page = totalPages
+		}
+
+		start := (page - 1) * perPage
+		if start > total {
+			start = total
+		}
+		end := start + perPage
+		if end > total {
+			end = total
+		}
+		pageDirs := dirs[start:end]
+
+		pageURL := func(p int) string {
+			v := url.Values{}
+			v.Set("sort", sortBy)
+			v.Set("order", order)
+			v.Set("per_page", strconv.Itoa(perPage))
+			v.Set("page", strconv.Itoa(p))
+			if q != "" {
+				v.Set("q", q)
+			}
+			return r.URL.Path + "?" + v.Encode()
 		}
 
 		title := options.Title
@@ -78,12 +217,31 @@ func DirListRich(options httpfs.DirListRichOptions) httpfs.DirListFunc {
 		}
 
 		pageData := listPageData{
-			Title:   title,
-			Files:   make([]fileInfoData, 0, len(dirs)),
-			RelPath: r.URL.Path,
+			Title:      title,
+			Files:      make([]fileInfoData, 0, len(pageDirs)),
+			RelPath:    r.URL.Path,
+			CSRFToken:  csrfToken,
+			Readonly:   readonly,
+			Query:      q,
+			Sort:       sortBy,
+			Order:      order,
+			NameOrder:  nextOrder("name"),
+			SizeOrder:  nextOrder("size"),
+			TimeOrder:  nextOrder("time"),
+			NumDirs:    numDirs,
+			NumFiles:   numFiles,
+			Page:       page,
+			PerPage:    perPage,
+			TotalPages: totalPages,
+		}
+		if page > 1 {
+			pageData.PrevPageURL = pageURL(page - 1)
+		}
+		if page < totalPages {
+			pageData.NextPageURL = pageURL(page + 1)
 		}
 
-		for _, d := range dirs {
+		for _, d := range pageDirs {
 			name := toBaseName(d.Name())
 
 			upath := path.Join(r.RequestURI, name)
@@ -96,12 +254,13 @@ func DirListRich(options httpfs.DirListRichOptions) httpfs.DirListFunc {
 
 			shouldDownload := dirOptions.Attachments.Enable && !d.IsDir()
 			pageData.Files = append(pageData.Files, fileInfoData{
-				Info:     d,
-				ModTime:  d.ModTime().UTC().Format(http.TimeFormat),
-				Path:     url.String(),
-				RelPath:  path.Join(r.URL.Path, name),
-				Name:     html.EscapeString(viewName),
-				Download: shouldDownload,
+				Info:      d,
+				ModTime:   d.ModTime().UTC().Format(http.TimeFormat),
+				Path:      url.String(),
+				RelPath:   path.Join(r.URL.Path, name),
+				Name:      html.EscapeString(viewName),
+				Download:  shouldDownload,
+				IsProfile: !d.IsDir() && isProfileFile(d.Name()),
 			})
 		}
 
@@ -125,22 +284,11 @@ var myHTMLTemplate = template.Must(template.New("dirlist.html").Funcs(template.F
 	"formatTime": func(t time.Time) string {
 		return t.Format("2006-01-02 15:04")
 	},
-	"isRoot": func(path string) bool {
-		return path == "/" || path == ""
-	},
+	"isRoot": isRoot,
 	"split": func(s string, sep string) []string {
 		return strings.Split(s, sep)
 	},
-	"parentPath": func(p string) string {
-		if strings.HasSuffix(p, "/") {
-			p = p[:len(p)-1]
-		}
-		lastSlash := strings.LastIndex(p, "/")
-		if lastSlash == 0 {
-			return "/" // 如果是根目录，返回"/"
-		}
-		return p[:lastSlash] // 返回父目录路径
-	},
+	"parentPath": parentPath,
 }).Parse(`
 <!DOCTYPE html>
 <html>
@@ -222,10 +370,71 @@ var myHTMLTemplate = template.Must(template.New("dirlist.html").Funcs(template.F
         .breadcrumb a {
             color: #0066cc;
         }
+        .toolbar {
+            display: flex;
+            flex-wrap: wrap;
+            gap: 10px;
+            align-items: center;
+            margin-bottom: 10px;
+        }
+        .toolbar form {
+            display: flex;
+            gap: 6px;
+            align-items: center;
+        }
+        .dropzone {
+            border: 2px dashed #bbb;
+            border-radius: 4px;
+            padding: 10px 15px;
+            color: #888;
+            font-size: 13px;
+        }
+        .dropzone.dragover {
+            border-color: #0066cc;
+            color: #0066cc;
+            background: #f0f7ff;
+        }
+        .delete-btn {
+            color: #c0392b;
+            cursor: pointer;
+            background: none;
+            border: none;
+            font-size: 13px;
+            padding: 0;
+        }
+        .action {
+            text-align: center;
+            width: 60px;
+        }
+        .listbar {
+            display: flex;
+            justify-content: space-between;
+            align-items: center;
+            margin-bottom: 8px;
+            font-size: 13px;
+            color: #666;
+        }
+        .listbar form {
+            display: flex;
+            gap: 6px;
+        }
+        th a {
+            color: #333;
+        }
+        .pagination {
+            display: flex;
+            justify-content: center;
+            gap: 15px;
+            margin-top: 10px;
+            font-size: 13px;
+        }
+        .pagination span.disabled {
+            color: #bbb;
+        }
     </style>
 </head>
 <body>
-    <div class="container">
+    <div class="container" data-csrf="{{.CSRFToken}}" data-path="{{.RelPath}}">
         <h1>{{.Title}}</h1>
 		<div class="breadcrumb">
 			当前目录：
@@ -238,49 +447,172 @@ var myHTMLTemplate = template.Must(template.New("dirlist.html").Funcs(template.F
                 {{end}}
             {{end}}
         </div>
-        <table>
-            <thead>
-                <tr>
-                    <th>名称</th>
-                    <th class="size">大小</th>
-                    <th class="time">修改时间</th>
-                </tr>
-            </thead>
-            <tbody>
-				{{if not (isRoot .RelPath)}}
-                <tr>
-                    <td colspan="3"><a href="{{parentPath .RelPath}}">.. (上级目录)</a></td>
-                </tr>
-                {{end}}
-
-                {{/* 先显示目录 */}}
-                {{range .Files}}
-                    {{if .Info.IsDir}}
+        {{if not .Readonly}}
+        <div class="toolbar">
+            <form method="post" enctype="multipart/form-data" action="{{.RelPath}}">
+                <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+                <input type="file" name="file" multiple>
+                <button type="submit">上传</button>
+            </form>
+            <form method="post" action="{{.RelPath}}?op=mkdir">
+                <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+                <input type="text" name="name" placeholder="新文件夹名称" required>
+                <button type="submit">新建文件夹</button>
+            </form>
+            <div class="dropzone" id="dropzone">将文件拖拽到此处上传</div>
+        </div>
+        {{end}}
+        <div class="listbar">
+            <span>{{.NumDirs}} 个目录，{{.NumFiles}} 个文件</span>
+            <form method="get" action="{{.RelPath}}">
+                <input type="text" name="q" value="{{.Query}}" placeholder="按名称过滤">
+                <input type="hidden" name="sort" value="{{.Sort}}">
+                <input type="hidden" name="order" value="{{.Order}}">
+                <input type="hidden" name="per_page" value="{{.PerPage}}">
+                <button type="submit">过滤</button>
+            </form>
+        </div>
+        <form id="compareForm" method="post" action="/compare">
+            <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+            <table>
+                <thead>
                     <tr>
-                        <td class="dir-icon">
-                            <a href="{{.Path}}">{{.Name}}</a>
-                        </td>
-                        <td class="size">-</td>
-                        <td class="time">{{formatTime .Info.ModTime}}</td>
+                        <th><a href="?sort=name&order={{.NameOrder}}&q={{.Query}}&per_page={{.PerPage}}">名称{{if eq .Sort "name"}} {{if eq .Order "asc"}}▲{{else}}▼{{end}}{{end}}</a></th>
+                        <th class="size"><a href="?sort=size&order={{.SizeOrder}}&q={{.Query}}&per_page={{.PerPage}}">大小{{if eq .Sort "size"}} {{if eq .Order "asc"}}▲{{else}}▼{{end}}{{end}}</a></th>
+                        <th class="time"><a href="?sort=time&order={{.TimeOrder}}&q={{.Query}}&per_page={{.PerPage}}">修改时间{{if eq .Sort "time"}} {{if eq .Order "asc"}}▲{{else}}▼{{end}}{{end}}</a></th>
+                        {{if not .Readonly}}<th class="action">操作</th>{{end}}
+                        <th class="action">对比</th>
                     </tr>
-                    {{end}}
-                {{end}}
-
-                {{/* 再显示文件 */}}
-                {{range .Files}}
-                    {{if not .Info.IsDir}}
+                </thead>
+                <tbody>
+                    {{if not (isRoot .RelPath)}}
                     <tr>
-                        <td class="file-icon">
-                            <a href="{{.Path}}" {{if .Download}}download{{end}}>{{.Name}}</a>
-                        </td>
-                        <td class="size">{{formatBytes .Info.Size}}</td>
-                        <td class="time">{{formatTime .Info.ModTime}}</td>
+                        <td colspan="5"><a href="{{parentPath .RelPath}}">.. (上级目录)</a></td>
                     </tr>
                     {{end}}
-                {{end}}
-            </tbody>
-        </table>
+
+                    {{/* 先显示目录 */}}
+                    {{range .Files}}
+                        {{if .Info.IsDir}}
+                        <tr>
+                            <td class="dir-icon">
+                                <a href="{{.Path}}">{{.Name}}</a>
+                            </td>
+                            <td class="size">-</td>
+                            <td class="time">{{formatTime .Info.ModTime}}</td>
+                            {{if not $.Readonly}}
+                            <td class="action"><button class="delete-btn" data-path="{{.RelPath}}" onclick="deleteEntry(this)">删除</button></td>
+                            {{end}}
+                            <td class="action"></td>
+                        </tr>
+                        {{end}}
+                    {{end}}
+
+                    {{/* 再显示文件 */}}
+                    {{range .Files}}
+                        {{if not .Info.IsDir}}
+                        <tr>
+                            <td class="file-icon">
+                                <a href="{{.Path}}" {{if .Download}}download{{end}}>{{.Name}}</a>
+                            </td>
+                            <td class="size">{{formatBytes .Info.Size}}</td>
+                            <td class="time">{{formatTime .Info.ModTime}}</td>
+                            {{if not $.Readonly}}
+                            <td class="action"><button class="delete-btn" data-path="{{.RelPath}}" onclick="deleteEntry(this)">删除</button></td>
+                            {{end}}
+                            <td class="action">{{if .IsProfile}}<input type="checkbox" name="paths" value="{{.RelPath}}" onchange="limitCompareSelection(this)">{{end}}</td>
+                        </tr>
+                        {{end}}
+                    {{end}}
+                </tbody>
+            </table>
+            <div class="toolbar">
+                <select name="mode">
+                    <option value="diff_base">差值对比 (diff_base)</option>
+                    <option value="base">基准相减 (base)</option>
+                </select>
+                <button type="submit">对比所选的两个文件</button>
+            </div>
+        </form>
+        <div class="pagination">
+            {{if .PrevPageURL}}<a href="{{.PrevPageURL}}">&laquo; 上一页</a>{{else}}<span class="disabled">&laquo; 上一页</span>{{end}}
+            <span>第 {{.Page}} / {{.TotalPages}} 页</span>
+            {{if .NextPageURL}}<a href="{{.NextPageURL}}">下一页 &raquo;</a>{{else}}<span class="disabled">下一页 &raquo;</span>{{end}}
+        </div>
     </div>
+    <script>
+        function limitCompareSelection(changed) {
+            var boxes = document.querySelectorAll('#compareForm input[name="paths"]');
+            var checked = document.querySelectorAll('#compareForm input[name="paths"]:checked');
+            if (checked.length >= 2) {
+                boxes.forEach(function(box) {
+                    if (!box.checked) {
+                        box.disabled = true;
+                    }
+                });
+            } else {
+                boxes.forEach(function(box) { box.disabled = false; });
+            }
+        }
+    </script>
+    {{if not .Readonly}}
+    <script>
+        (function() {
+            var container = document.querySelector('.container');
+            var csrf = container.dataset.csrf;
+            var dropzone = document.getElementById('dropzone');
+
+            function upload(files) {
+                var form = new FormData();
+                for (var i = 0; i < files.length; i++) {
+                    form.append('file', files[i]);
+                }
+                fetch(container.dataset.path, {
+                    method: 'POST',
+                    headers: {'X-CSRF-Token': csrf},
+                    body: form
+                }).then(function(resp) {
+                    if (resp.redirected) {
+                        window.location = resp.url;
+                    } else {
+                        window.location.reload();
+                    }
+                });
+            }
+
+            dropzone.addEventListener('dragover', function(e) {
+                e.preventDefault();
+                dropzone.classList.add('dragover');
+            });
+            dropzone.addEventListener('dragleave', function() {
+                dropzone.classList.remove('dragover');
+            });
+            dropzone.addEventListener('drop', function(e) {
+                e.preventDefault();
+                dropzone.classList.remove('dragover');
+                if (e.dataTransfer.files.length) {
+                    upload(e.dataTransfer.files);
+                }
+            });
+
+            window.deleteEntry = function(btn) {
+                if (!confirm('确定要删除吗？')) {
+                    return;
+                }
+                fetch(btn.dataset.path, {
+                    method: 'DELETE',
+                    headers: {'X-CSRF-Token': csrf}
+                }).then(function(resp) {
+                    if (resp.ok) {
+                        window.location.reload();
+                    } else {
+                        alert('删除失败');
+                    }
+                });
+            };
+        })();
+    </script>
+    {{end}}
 </body>
 </html>
 `))
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readonly disables every mutating route (upload, delete, mkdir, capture) when set.
+var readonly bool
+
+// csrfToken protects the mutating routes against cross-site request forgery.
+// It is generated once at startup and embedded into every rendered listing page.
+var csrfToken = generateCSRFToken()
+
+func generateCSRFToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand failing is effectively unrecoverable, but don't refuse to start over it.
+		return fmt.Sprintf("%x", b)
+	}
+	return hex.EncodeToString(b)
+}
+
+// checkCSRF accepts the token either as an X-CSRF-Token header (used by the
+// drag-and-drop/delete JS) or as a csrf_token form field (used by plain forms).
+func checkCSRF(c *gin.Context) bool {
+	token := c.GetHeader("X-CSRF-Token")
+	if token == "" {
+		token = c.PostForm("csrf_token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(csrfToken)) == 1
+}
+
+// isProfileFile reports whether name looks like a pprof-capturable profile.
+func isProfileFile(name string) bool {
+	return strings.HasSuffix(name, ".pb.gz") || strings.HasSuffix(name, ".pprof") || strings.HasSuffix(name, ".out")
+}
+
+// uploadHandler handles POST /*filepath: either a multipart file upload into
+// the requested directory, or folder creation when called as ?op=mkdir.
+func uploadHandler(c *gin.Context) {
+	if !checkCSRF(c) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	dir, err := resolveJailed(filepath.Join(repoPath, c.Param("filepath")))
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	if c.Query("op") == "mkdir" {
+		name := c.PostForm("name")
+		if name == "" || strings.ContainsAny(name, "/\\") {
+			c.AbortWithError(http.StatusBadRequest, fmt.Errorf("invalid folder name: %q", name))
+			return
+		}
+		if err := os.Mkdir(filepath.Join(dir, name), 0o755); err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		c.Redirect(http.StatusFound, c.Request.URL.Path)
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.AbortWithError(http.StatusBadRequest, err)
+		return
+	}
+
+	var lastSaved string
+	for _, fh := range form.File["file"] {
+		dst := filepath.Join(dir, filepath.Base(fh.Filename))
+		if err := c.SaveUploadedFile(fh, dst); err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		lastSaved = dst
+	}
+
+	if lastSaved != "" && isProfileFile(lastSaved) {
+		rel, err := filepath.Rel(repoPath, lastSaved)
+		if err == nil {
+			c.Redirect(http.StatusFound, path.Join("/", filepath.ToSlash(rel))+"/ui/")
+			return
+		}
+	}
+
+	c.Redirect(http.StatusFound, c.Request.URL.Path)
+}
+
+// deleteHandler handles DELETE /*filepath, removing the file or directory
+// and evicting any cached pprof handler for it.
+func deleteHandler(c *gin.Context) {
+	if !checkCSRF(c) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	fp, err := resolveJailed(filepath.Join(repoPath, c.Param("filepath")))
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if isRepoRoot(fp) {
+		c.AbortWithError(http.StatusBadRequest, fmt.Errorf("refusing to delete repoPath itself"))
+		return
+	}
+
+	if err := os.RemoveAll(fp); err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	profileCache.Delete(fp)
+	c.Status(http.StatusNoContent)
+}